@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const anomalyStateCollection = "anomaly_state"
+
+type anomalyState string
+
+const (
+	anomalyStateReceived     anomalyState = "received"
+	anomalyStateTriaged      anomalyState = "triaged"
+	anomalyStateRerouted     anomalyState = "rerouted"
+	anomalyStateAcknowledged anomalyState = "acknowledged"
+	anomalyStateResolved     anomalyState = "resolved"
+	anomalyStateFailed       anomalyState = "failed"
+)
+
+// anomalyTransitions is the table-driven FSM for a sensor's anomaly
+// lifecycle. The empty state means "no anomaly_state document yet" and is
+// the entry point; resolved/failed are terminal and transition to
+// themselves so a late-arriving PublishHealth call doesn't error out.
+var anomalyTransitions = map[anomalyState]anomalyState{
+	"":                       anomalyStateReceived,
+	anomalyStateReceived:     anomalyStateTriaged,
+	anomalyStateTriaged:      anomalyStateRerouted,
+	anomalyStateRerouted:     anomalyStateAcknowledged,
+	anomalyStateAcknowledged: anomalyStateResolved,
+	anomalyStateResolved:     anomalyStateResolved,
+	anomalyStateFailed:       anomalyStateFailed,
+}
+
+// nextAnomalyState looks up the successor of current. Any state absent
+// from anomalyTransitions (which should not happen for the constants
+// above) defaults to failed rather than panicking.
+func nextAnomalyState(current anomalyState) anomalyState {
+	if next, ok := anomalyTransitions[current]; ok {
+		return next
+	}
+	return anomalyStateFailed
+}
+
+// anomalyStateDoc is the anomaly_state document for one sensor: current
+// FSM state plus the full event history, with version used for optimistic
+// concurrency so two concurrent PublishHealth calls for the same sensor
+// can't silently clobber each other's transition.
+type anomalyStateDoc struct {
+	SensorID  string       `bson:"sensorId"`
+	State     anomalyState `bson:"state"`
+	Version   int64        `bson:"version"`
+	UpdatedAt time.Time    `bson:"updatedAt"`
+	Events    []bson.M     `bson:"events"`
+}
+
+// ensureAnomalyStateIndexes creates the unique index on sensorId that
+// advanceAnomalyState's first-insert-wins retry loop relies on: without it,
+// two concurrent PublishHealth calls for a brand-new sensorId would both
+// read ErrNoDocuments and both InsertOne successfully instead of one
+// failing with a duplicate-key error. It's idempotent, so it's safe to call
+// on every startup.
+func ensureAnomalyStateIndexes(ctx context.Context, mongoClient *mongo.Client, database string) error {
+	collection := mongoClient.Database(database).Collection(anomalyStateCollection)
+	_, err := collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.M{"sensorId": 1},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return fmt.Errorf("create anomaly_state sensorId index: %w", err)
+	}
+	return nil
+}
+
+// advanceAnomalyState moves sensorID's FSM to its next state and appends
+// an event recording the transition. The first call for a sensor inserts
+// a seed document at version 1; later calls advance it with a
+// version-guarded update, retrying if it loses the optimistic-concurrency
+// race against a concurrent caller.
+func (s *redundancyService) advanceAnomalyState(ctx context.Context, sensorID string, payload map[string]interface{}) (*anomalyStateDoc, error) {
+	collection := s.mongoClient.Database(s.mongoDatabase).Collection(anomalyStateCollection)
+
+	const maxAttempts = 5
+	for attempt := 0; attempt < maxAttempts; attempt++ {
+		var current anomalyStateDoc
+		err := collection.FindOne(ctx, bson.M{"sensorId": sensorID}).Decode(&current)
+		switch {
+		case errors.Is(err, mongo.ErrNoDocuments):
+			current = anomalyStateDoc{SensorID: sensorID}
+		case err != nil:
+			return nil, fmt.Errorf("load anomaly state: %w", err)
+		}
+
+		next := nextAnomalyState(current.State)
+		event := bson.M{
+			"state":      next,
+			"reason":     payload["reason"],
+			"timestamp":  payload["timestamp"],
+			"recordedAt": time.Now().UTC(),
+		}
+
+		if current.Version == 0 {
+			doc := anomalyStateDoc{
+				SensorID:  sensorID,
+				State:     next,
+				Version:   1,
+				UpdatedAt: time.Now().UTC(),
+				Events:    []bson.M{event},
+			}
+			if _, err := collection.InsertOne(ctx, doc); err != nil {
+				if mongo.IsDuplicateKeyError(err) {
+					continue // another caller seeded it first; retry and advance from there
+				}
+				return nil, fmt.Errorf("insert anomaly state: %w", err)
+			}
+			return &doc, nil
+		}
+
+		result, err := collection.UpdateOne(ctx,
+			bson.M{"sensorId": sensorID, "version": current.Version},
+			bson.M{
+				"$set":  bson.M{"state": next, "updatedAt": time.Now().UTC()},
+				"$inc":  bson.M{"version": 1},
+				"$push": bson.M{"events": event},
+			},
+		)
+		if err != nil {
+			return nil, fmt.Errorf("advance anomaly state: %w", err)
+		}
+		if result.MatchedCount == 0 {
+			continue // lost the optimistic-concurrency race; retry from the latest version
+		}
+
+		current.State = next
+		current.Version++
+		current.Events = append(current.Events, event)
+		return &current, nil
+	}
+
+	return nil, fmt.Errorf("advance anomaly state for %s: exhausted retries", sensorID)
+}
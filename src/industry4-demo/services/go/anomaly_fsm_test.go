@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+func TestNextAnomalyState(t *testing.T) {
+	cases := []struct {
+		current anomalyState
+		want    anomalyState
+	}{
+		{"", anomalyStateReceived},
+		{anomalyStateReceived, anomalyStateTriaged},
+		{anomalyStateTriaged, anomalyStateRerouted},
+		{anomalyStateRerouted, anomalyStateAcknowledged},
+		{anomalyStateAcknowledged, anomalyStateResolved},
+		{anomalyStateResolved, anomalyStateResolved},
+		{anomalyStateFailed, anomalyStateFailed},
+		{anomalyState("bogus"), anomalyStateFailed},
+	}
+
+	for _, tc := range cases {
+		if got := nextAnomalyState(tc.current); got != tc.want {
+			t.Errorf("nextAnomalyState(%q) = %q, want %q", tc.current, got, tc.want)
+		}
+	}
+}
+
+func TestAdvanceAnomalyStateRetriesOnDuplicateInsert(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("duplicate key on seed insert", func(mt *mtest.T) {
+		s := &redundancyService{mongoClient: mt.Client, mongoDatabase: mt.DB.Name()}
+
+		ns := mt.DB.Name() + "." + mt.Coll.Name()
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, ns, mtest.FirstBatch),
+			mtest.CreateCommandErrorResponse(mtest.CommandError{Code: 11000, Name: "DuplicateKey", Message: "E11000 duplicate key"}),
+			mtest.CreateCursorResponse(0, ns, mtest.FirstBatch,
+				bson.D{{"sensorId", "sensor-1"}, {"state", string(anomalyStateReceived)}, {"version", int64(1)}},
+			),
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}, bson.E{Key: "nModified", Value: 1}),
+		)
+
+		doc, err := s.advanceAnomalyState(context.Background(), "sensor-1", map[string]interface{}{"reason": "temp"})
+		if err != nil {
+			t.Fatalf("advanceAnomalyState returned error: %v", err)
+		}
+		if doc.State != anomalyStateTriaged {
+			t.Errorf("State = %q, want %q", doc.State, anomalyStateTriaged)
+		}
+	})
+}
+
+func TestAdvanceAnomalyStateRetriesOnLostUpdateRace(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("matched count zero on update", func(mt *mtest.T) {
+		s := &redundancyService{mongoClient: mt.Client, mongoDatabase: mt.DB.Name()}
+
+		ns := mt.DB.Name() + "." + mt.Coll.Name()
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, ns, mtest.FirstBatch,
+				bson.D{{"sensorId", "sensor-2"}, {"state", string(anomalyStateReceived)}, {"version", int64(1)}},
+			),
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 0}, bson.E{Key: "nModified", Value: 0}),
+			mtest.CreateCursorResponse(0, ns, mtest.FirstBatch,
+				bson.D{{"sensorId", "sensor-2"}, {"state", string(anomalyStateTriaged)}, {"version", int64(2)}},
+			),
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}, bson.E{Key: "nModified", Value: 1}),
+		)
+
+		doc, err := s.advanceAnomalyState(context.Background(), "sensor-2", map[string]interface{}{"reason": "temp"})
+		if err != nil {
+			t.Fatalf("advanceAnomalyState returned error: %v", err)
+		}
+		if doc.State != anomalyStateRerouted {
+			t.Errorf("State = %q, want %q", doc.State, anomalyStateRerouted)
+		}
+	})
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jhump/protoreflect/dynamic"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// getAnomalyStateHandler answers GetAnomalyState with the current FSM
+// state and full transition history for a sensor, straight out of the
+// anomaly_state collection advanceAnomalyState maintains.
+func (s *redundancyService) getAnomalyStateHandler(ctx context.Context, req *dynamic.Message) (*dynamic.Message, error) {
+	sensorID, _ := req.TryGetField(s.getAnomalyStateReqDesc.FindFieldByName("sensorId"))
+
+	collection := s.mongoClient.Database(s.mongoDatabase).Collection(anomalyStateCollection)
+	var doc anomalyStateDoc
+	err := collection.FindOne(ctx, bson.M{"sensorId": sensorID}).Decode(&doc)
+
+	resp := dynamic.NewMessage(s.getAnomalyStateRespDesc)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		resp.SetFieldByName("state", string(anomalyState("")))
+		return resp, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("load anomaly state: %w", err)
+	}
+
+	resp.SetFieldByName("state", string(doc.State))
+	resp.SetFieldByName("version", doc.Version)
+
+	historyField := s.getAnomalyStateRespDesc.FindFieldByName("history")
+	eventDesc := historyField.GetMessageType()
+	history := make([]interface{}, 0, len(doc.Events))
+	for _, event := range doc.Events {
+		entry := dynamic.NewMessage(eventDesc)
+		entry.SetFieldByName("state", fmt.Sprint(event["state"]))
+		entry.SetFieldByName("reason", fmt.Sprint(event["reason"]))
+		entry.SetFieldByName("timestamp", fmt.Sprint(event["timestamp"]))
+		history = append(history, entry)
+	}
+	if err := resp.TrySetField(historyField, history); err != nil {
+		return nil, fmt.Errorf("set history field: %w", err)
+	}
+
+	return resp, nil
+}
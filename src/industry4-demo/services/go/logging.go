@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+
+	"github.com/afex/hystrix-go/hystrix"
+	metricCollector "github.com/afex/hystrix-go/hystrix/metric_collector"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+const correlationIDMetadataKey = "correlation-id"
+
+type correlationIDContextKey struct{}
+type loggerContextKey struct{}
+
+// newCorrelationID generates a random 16-byte hex ID for requests that
+// don't already carry one in their "correlation-id" metadata.
+func newCorrelationID() string {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "unknown"
+	}
+	return hex.EncodeToString(buf)
+}
+
+// correlationIDUnaryInterceptor extracts the "correlation-id" from
+// incoming gRPC metadata (generating one if absent), stashes it on the
+// request context, and derives a child logger carrying it plus the
+// method name so every log line for this call is correlatable. Handlers
+// should read it back via loggerFromContext/correlationIDFromContext
+// instead of reaching for s.logger directly.
+func correlationIDUnaryInterceptor(logger *slog.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		id := incomingCorrelationID(ctx)
+		ctx = context.WithValue(ctx, correlationIDContextKey{}, id)
+		ctx = contextWithLogger(ctx, logger.With("correlation_id", id, "method", info.FullMethod))
+		return handler(ctx, req)
+	}
+}
+
+func incomingCorrelationID(ctx context.Context) string {
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get(correlationIDMetadataKey); len(values) > 0 && values[0] != "" {
+			return values[0]
+		}
+	}
+	return newCorrelationID()
+}
+
+func contextWithLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerContextKey{}, logger)
+}
+
+// loggerFromContext returns the per-request logger the correlation ID
+// interceptor attached to ctx, falling back to fallback (s.logger) for
+// contexts that never passed through it, e.g. the streaming RPC path.
+func loggerFromContext(ctx context.Context, fallback *slog.Logger) *slog.Logger {
+	if logger, ok := ctx.Value(loggerContextKey{}).(*slog.Logger); ok {
+		return logger
+	}
+	return fallback
+}
+
+func correlationIDFromContext(ctx context.Context) string {
+	if id, ok := ctx.Value(correlationIDContextKey{}).(string); ok {
+		return id
+	}
+	return ""
+}
+
+// circuitStateLogger is a Hystrix metric collector whose only job is to
+// log when a command's circuit flips open or closed, so a fallback being
+// triggered can be traced back to the circuit transition that caused it
+// through the same correlatable logger as everything else.
+type circuitStateLogger struct {
+	logger *slog.Logger
+	name   string
+	open   bool
+}
+
+func newCircuitStateLoggerFactory(logger *slog.Logger) func(name string) metricCollector.MetricCollector {
+	return func(name string) metricCollector.MetricCollector {
+		return &circuitStateLogger{logger: logger, name: name}
+	}
+}
+
+func (c *circuitStateLogger) Update(_ metricCollector.MetricResult) {
+	open, err := circuitIsOpen(c.name)
+	if err != nil || open == c.open {
+		return
+	}
+	c.open = open
+	state := "closed"
+	if open {
+		state = "open"
+	}
+	c.logger.Warn("Hystrix circuit state changed", "command", c.name, "state", state)
+}
+
+func (c *circuitStateLogger) Reset() {}
+
+// circuitIsOpen reports whether the named Hystrix command's circuit is
+// currently open. hystrix.GetCircuit's second return value is whether the
+// circuit was just created, not its open/closed state, so callers must go
+// through the breaker's IsOpen() rather than using that bool directly.
+func circuitIsOpen(name string) (bool, error) {
+	cb, _, err := hystrix.GetCircuit(name)
+	if err != nil {
+		return false, err
+	}
+	return cb.IsOpen(), nil
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var (
+	requestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "mms_redundancy_requests_total",
+		Help: "Total RPCs handled, by method and outcome.",
+	}, []string{"method", "outcome"})
+
+	anomaliesTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "mms_redundancy_anomalies_total",
+		Help: "Total anomalies reported through PublishHealth.",
+	})
+
+	fallbacksTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "mms_redundancy_fallbacks_total",
+		Help: "Total Hystrix fallbacks triggered, by command.",
+	}, []string{"command"})
+
+	handlerLatencySeconds = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "mms_redundancy_handler_latency_seconds",
+		Help:    "RPC handler latency in seconds, by method.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method"})
+
+	mongoInsertLatencyMs = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "mms_redundancy_mongo_insert_latency_ms",
+		Help:    "processAnomaly's Mongo insert latency in milliseconds (the same value persisted as latencyMs).",
+		Buckets: prometheus.ExponentialBuckets(1, 2, 12),
+	})
+
+	circuitOpen = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "mms_redundancy_circuit_open",
+		Help: "1 if a Hystrix circuit is currently open, 0 if closed.",
+	}, []string{"command"})
+
+	alertSinkQueueDepth = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "mms_redundancy_alert_sink_queue_depth",
+		Help: "In-memory queue depth for alert sinks that buffer asynchronously (e.g. sentry).",
+	}, []string{"sink"})
+)
+
+// outcomeLabel turns a handler error into the "ok"/"error" label value used
+// by requestsTotal.
+func outcomeLabel(err error) string {
+	if err != nil {
+		return "error"
+	}
+	return "ok"
+}
+
+// depthReporter is implemented by alert sinks that buffer asynchronously
+// and can report how full that buffer currently is.
+type depthReporter interface {
+	QueueDepth() int
+}
+
+// pinger is implemented by alert sinks whose transport can be health
+// checked directly, for the /readyz endpoint.
+type pinger interface {
+	Ping() error
+}
+
+// pollMetrics periodically refreshes the gauges that can't be updated
+// inline from a request path: Hystrix circuit state (polled via
+// circuitIsOpen) and alert sink queue depth. It runs until ctx is
+// canceled.
+func pollMetrics(ctx context.Context, commandNames []string, sinks []AlertSink, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for _, name := range commandNames {
+				open, err := circuitIsOpen(name)
+				if err != nil {
+					continue
+				}
+				value := 0.0
+				if open {
+					value = 1.0
+				}
+				circuitOpen.WithLabelValues(name).Set(value)
+			}
+			for _, sink := range sinks {
+				if reporter, ok := sink.(depthReporter); ok {
+					alertSinkQueueDepth.WithLabelValues(sink.Name()).Set(float64(reporter.QueueDepth()))
+				}
+			}
+		}
+	}
+}
+
+// newMetricsServer builds the sidecar HTTP server exposing /metrics,
+// /healthz (liveness) and /readyz (Mongo + alert sink connectivity).
+func newMetricsServer(addr string, logger *slog.Logger, mongoClient *mongo.Client, sinks []AlertSink) *http.Server {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte("ok"))
+	})
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
+		defer cancel()
+
+		if err := mongoClient.Ping(ctx, nil); err != nil {
+			logger.Warn("readyz: mongo ping failed", "error", err)
+			http.Error(w, "mongo not ready", http.StatusServiceUnavailable)
+			return
+		}
+
+		for _, sink := range sinks {
+			if p, ok := sink.(pinger); ok {
+				if err := p.Ping(); err != nil {
+					logger.Warn("readyz: alert sink ping failed", "sink", sink.Name(), "error", err)
+					http.Error(w, "alert sink not ready", http.StatusServiceUnavailable)
+					return
+				}
+			}
+		}
+
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte("ok"))
+	})
+
+	return &http.Server{Addr: addr, Handler: mux}
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/afex/hystrix-go/hystrix"
+	"github.com/jhump/protoreflect/desc"
+	"github.com/jhump/protoreflect/dynamic"
+	"go.mongodb.org/mongo-driver/bson"
+	"google.golang.org/grpc"
+)
+
+const publishStreamCommandName = "publish-health-stream"
+
+// publishStreamBatchSize and publishStreamFlushInterval are the defaults
+// for PUBLISH_STREAM_BATCH_SIZE and PUBLISH_STREAM_FLUSH_INTERVAL, read
+// once at startup in main and threaded through redundancyService as
+// streamBatchSize/streamFlushInterval.
+const (
+	publishStreamBatchSize     = 500
+	publishStreamFlushInterval = 200 * time.Millisecond
+)
+
+// publishHealthStreamHandler implements the client-streaming
+// PublishHealthStream RPC: like the unary PublishHealth handler, it only
+// persists and alerts on messages with anomaly == true, accumulating those
+// into batches flushed whenever the batch reaches s.streamBatchSize or
+// s.streamFlushInterval elapses, whichever comes first. Each flush is a
+// single Mongo InsertMany plus a single alert publish carrying the whole
+// batch as a JSON array, which amortizes the per-message overhead the
+// unary PublishHealth path pays on every call. Non-anomalous readings are
+// still counted toward "received" but otherwise just acked.
+func (s *redundancyService) publishHealthStreamHandler(stream grpc.ServerStream) error {
+	ctx := stream.Context()
+
+	var (
+		mu         sync.Mutex
+		batch      []map[string]interface{}
+		received   int64
+		batchCount int64
+	)
+
+	flush := func() error {
+		mu.Lock()
+		toFlush := batch
+		batch = nil
+		if len(toFlush) > 0 {
+			batchCount++
+		}
+		mu.Unlock()
+		if len(toFlush) == 0 {
+			return nil
+		}
+		return hystrix.Do(publishStreamCommandName, func() error {
+			return s.flushHealthBatch(ctx, toFlush)
+		}, nil)
+	}
+
+	ticker := time.NewTicker(s.streamFlushInterval)
+	defer ticker.Stop()
+
+	flushErrs := make(chan error, 1)
+	stopTicker := make(chan struct{})
+	defer close(stopTicker)
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				if err := flush(); err != nil {
+					select {
+					case flushErrs <- err:
+					default:
+					}
+				}
+			case <-stopTicker:
+				return
+			}
+		}
+	}()
+
+	// recvMsg is driven from its own goroutine so the main loop below can
+	// select between it and flushErrs: without this, a flush error raised
+	// by the ticker goroutine while we're blocked in stream.RecvMsg
+	// wouldn't surface until the next message arrived (or never, if the
+	// client went quiet).
+	type recvResult struct {
+		msg *dynamic.Message
+		err error
+	}
+	recvCh := make(chan recvResult)
+	recvDone := make(chan struct{})
+	defer close(recvDone)
+	go func() {
+		for {
+			msg := dynamic.NewMessage(s.publishReqDesc)
+			err := stream.RecvMsg(msg)
+			select {
+			case recvCh <- recvResult{msg: msg, err: err}:
+			case <-recvDone:
+				return
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	for {
+		select {
+		case err := <-flushErrs:
+			return err
+		case res := <-recvCh:
+			if res.err != nil {
+				if res.err != io.EOF {
+					return res.err
+				}
+				if err := flush(); err != nil {
+					return err
+				}
+				mu.Lock()
+				batches := batchCount
+				mu.Unlock()
+				resp := dynamic.NewMessage(s.publishStreamRespDesc)
+				resp.SetFieldByName("received", received)
+				resp.SetFieldByName("batches", batches)
+				return stream.SendMsg(resp)
+			}
+
+			anomalyField := s.publishReqDesc.FindFieldByName("anomaly")
+			anomalyRaw, _ := res.msg.TryGetField(anomalyField)
+			received++
+
+			if anomalyRaw == true {
+				mu.Lock()
+				batch = append(batch, publishRequestToPayload(s.publishReqDesc, res.msg))
+				shouldFlush := len(batch) >= s.streamBatchSize
+				mu.Unlock()
+
+				if shouldFlush {
+					if err := flush(); err != nil {
+						return err
+					}
+				}
+			}
+		}
+	}
+}
+
+// flushHealthBatch persists one PublishHealthStream batch with a single
+// InsertMany and forwards it to the pubsub-backed alert sink as one
+// message, rather than fanning each reading out individually.
+func (s *redundancyService) flushHealthBatch(ctx context.Context, batch []map[string]interface{}) error {
+	collection := s.mongoClient.Database(s.mongoDatabase).Collection(s.mongoCollection)
+
+	docs := make([]interface{}, 0, len(batch))
+	for _, payload := range batch {
+		docs = append(docs, bson.M{
+			"factory":     payload["factory"],
+			"sensorId":    payload["sensorId"],
+			"reason":      payload["reason"],
+			"anomaly":     payload["anomaly"],
+			"timestamp":   payload["timestamp"],
+			"processedAt": time.Now().UTC(),
+		})
+	}
+	if _, err := collection.InsertMany(ctx, docs); err != nil {
+		return fmt.Errorf("mongo insert many: %w", err)
+	}
+
+	if err := s.publishBatchAlert(ctx, batch); err != nil {
+		return fmt.Errorf("publish batch alert: %w", err)
+	}
+
+	loggerFromContext(ctx, s.logger).Info("Flushed PublishHealthStream batch", "size", len(batch))
+	return nil
+}
+
+// publishBatchAlert forwards a PublishHealthStream batch to the
+// pubsub-backed sink, if one is configured. Other sinks (Sentry, the
+// downstream gRPC HealthService) expect a single-anomaly shape, so batches
+// only travel over the broker sink rather than the full AlertSink fan-out.
+func (s *redundancyService) publishBatchAlert(ctx context.Context, batch []map[string]interface{}) error {
+	for _, sink := range s.alertSinks {
+		if pubsubSink, ok := sink.(*pubsubAlertSink); ok {
+			return pubsubSink.PublishBatch(ctx, batch)
+		}
+	}
+	return nil
+}
+
+func publishRequestToPayload(reqDesc *desc.MessageDescriptor, msg *dynamic.Message) map[string]interface{} {
+	sensorID, _ := msg.TryGetField(reqDesc.FindFieldByName("sensorId"))
+	anomalyRaw, _ := msg.TryGetField(reqDesc.FindFieldByName("anomaly"))
+	reason, _ := msg.TryGetField(reqDesc.FindFieldByName("reason"))
+	timestamp, _ := msg.TryGetField(reqDesc.FindFieldByName("timestamp"))
+
+	return map[string]interface{}{
+		"factory":   getenvDefault("DEFAULT_FACTORY", "factory-1"),
+		"sensorId":  sensorID,
+		"anomaly":   anomalyRaw == true,
+		"reason":    reason,
+		"timestamp": timestamp,
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jhump/protoreflect/desc"
+	"github.com/jhump/protoreflect/dynamic"
+	"google.golang.org/grpc"
+)
+
+// unaryHandlerFunc implements a single unary RPC against dynamic messages
+// built from the descriptors parsed out of health.proto.
+type unaryHandlerFunc func(ctx context.Context, req *dynamic.Message) (*dynamic.Message, error)
+
+// streamHandlerFunc implements a single streaming RPC (client-, server- or
+// bidi-streaming) directly against the raw grpc.ServerStream.
+type streamHandlerFunc func(stream grpc.ServerStream) error
+
+// registerHandler wires a unary RPC name to its implementation. Call it
+// once per RPC after the proto descriptors are parsed; registerService
+// dispatches into this map by name instead of each RPC needing its own
+// hand-written grpc.MethodDesc.
+func (s *redundancyService) registerHandler(method string, handler unaryHandlerFunc) {
+	if s.handlers == nil {
+		s.handlers = make(map[string]unaryHandlerFunc)
+	}
+	s.handlers[method] = handler
+}
+
+// registerStreamHandler is registerHandler's counterpart for streaming
+// RPCs.
+func (s *redundancyService) registerStreamHandler(method string, handler streamHandlerFunc) {
+	if s.streamHandlers == nil {
+		s.streamHandlers = make(map[string]streamHandlerFunc)
+	}
+	s.streamHandlers[method] = handler
+}
+
+// registerService builds a grpc.ServiceDesc directly from serviceDesc by
+// walking every method on it: unary methods become grpc.MethodDesc
+// entries and streaming methods (server-, client- or bidi-) become
+// grpc.StreamDesc entries. Both dispatch by method name into svc's
+// handler maps, so a new RPC only needs a proto edit plus a call to
+// registerHandler/registerStreamHandler -- not a new case in this
+// function.
+func registerService(grpcServer *grpc.Server, serviceDesc *desc.ServiceDescriptor, svc *redundancyService) {
+	svcDesc := &grpc.ServiceDesc{
+		ServiceName: serviceDesc.GetFullyQualifiedName(),
+		HandlerType: (*healthServiceServer)(nil),
+		Metadata:    "health.proto",
+	}
+
+	for _, method := range serviceDesc.GetMethods() {
+		methodName := method.GetName()
+		fullMethod := fmt.Sprintf("/%s/%s", serviceDesc.GetFullyQualifiedName(), methodName)
+
+		if method.IsClientStreaming() || method.IsServerStreaming() {
+			svcDesc.Streams = append(svcDesc.Streams, grpc.StreamDesc{
+				StreamName:    methodName,
+				Handler:       newStreamDispatcher(svc, methodName),
+				ServerStreams: method.IsServerStreaming(),
+				ClientStreams: method.IsClientStreaming(),
+			})
+			continue
+		}
+
+		svcDesc.Methods = append(svcDesc.Methods, grpc.MethodDesc{
+			MethodName: methodName,
+			Handler:    newUnaryDispatcher(svc, methodName, method.GetInputType(), fullMethod),
+		})
+	}
+
+	grpcServer.RegisterService(svcDesc, svc)
+}
+
+func newUnaryDispatcher(svc *redundancyService, methodName string, reqDesc *desc.MessageDescriptor, fullMethod string) func(interface{}, context.Context, func(interface{}) error, grpc.UnaryServerInterceptor) (interface{}, error) {
+	return func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+		in := dynamic.NewMessage(reqDesc)
+		if err := dec(in); err != nil {
+			return nil, err
+		}
+
+		handler, ok := svc.handlers[methodName]
+		if !ok {
+			return nil, fmt.Errorf("no handler registered for method %s", methodName)
+		}
+		if interceptor == nil {
+			return handler(ctx, in)
+		}
+
+		info := &grpc.UnaryServerInfo{Server: srv, FullMethod: fullMethod}
+		return interceptor(ctx, in, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+			return handler(ctx, req.(*dynamic.Message))
+		})
+	}
+}
+
+func newStreamDispatcher(svc *redundancyService, methodName string) func(interface{}, grpc.ServerStream) error {
+	return func(srv interface{}, stream grpc.ServerStream) error {
+		handler, ok := svc.streamHandlers[methodName]
+		if !ok {
+			return fmt.Errorf("no stream handler registered for method %s", methodName)
+		}
+		return handler(stream)
+	}
+}